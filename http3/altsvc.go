@@ -0,0 +1,163 @@
+package http3
+
+import (
+	"crypto/tls"
+	"errors"
+	"net/http"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	quic "github.com/lucas-clemente/quic-go"
+)
+
+// errBodyNotReplayable is returned when a request with a body that can't be
+// safely read twice (no GetBody) would need to be attempted on both the
+// QUIC and the fallback transport.
+var errBodyNotReplayable = errors.New("http3: request body does not support GetBody, cannot safely retry across transports")
+
+// altSvcH3Entry matches the "h3" product of an Alt-Svc header field, e.g.
+// `h3=":443"; ma=3600`, capturing the advertised port and the optional
+// max-age.
+var altSvcH3Entry = regexp.MustCompile(`h3="?:(\d+)"?\s*(?:;\s*ma=(\d+))?`)
+
+// defaultAltSvcMaxAge is used when the server didn't send a "ma" parameter,
+// matching the default assumed by most HTTP/2 Alt-Svc implementations.
+const defaultAltSvcMaxAge = 24 * time.Hour
+
+// AltSvcRoundTripper is a http.RoundTripper that starts out talking to an
+// origin over Fallback (typically HTTP/1.1 or HTTP/2 over TLS) and
+// transparently upgrades subsequent requests to that origin to HTTP/3, once
+// an "Alt-Svc" response header advertises support for it. It falls back
+// again once the advertisement expires, or if dialing QUIC fails.
+type AltSvcRoundTripper struct {
+	// Fallback handles the first request to an origin, and any request for
+	// which the cached Alt-Svc advertisement has expired or QUIC dialing
+	// failed. If nil, http.DefaultTransport is used.
+	Fallback http.RoundTripper
+
+	TLSClientConfig *tls.Config
+	QuicConfig      *quic.Config
+
+	initOnce sync.Once
+	h3       *RoundTripper
+
+	mu    sync.Mutex
+	cache map[string]altSvcEntry
+}
+
+type altSvcEntry struct {
+	port   string
+	expiry time.Time
+}
+
+func (rt *AltSvcRoundTripper) init() {
+	rt.h3 = &RoundTripper{TLSClientConfig: rt.TLSClientConfig, QuicConfig: rt.QuicConfig}
+	rt.cache = make(map[string]altSvcEntry)
+}
+
+func (rt *AltSvcRoundTripper) fallback() http.RoundTripper {
+	if rt.Fallback != nil {
+		return rt.Fallback
+	}
+	return http.DefaultTransport
+}
+
+// RoundTrip implements http.RoundTripper.
+func (rt *AltSvcRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	rt.initOnce.Do(rt.init)
+
+	origin := req.URL.Scheme + "://" + req.URL.Host
+	rt.mu.Lock()
+	entry, ok := rt.cache[origin]
+	rt.mu.Unlock()
+
+	if ok && time.Now().Before(entry.expiry) {
+		if h3Req, err := bodyForRetry(req); err == nil {
+			rsp, err := rt.roundTripH3(h3Req, entry.port)
+			if err == nil {
+				return rsp, nil
+			}
+			// The QUIC dial failed (e.g. blocked UDP, server restarted
+			// without the advertised support): evict the entry and fall
+			// back for this request (whose body is untouched, since
+			// h3Req carried its own independent copy) and any future one,
+			// until the origin advertises again.
+			rt.mu.Lock()
+			delete(rt.cache, origin)
+			rt.mu.Unlock()
+		}
+		// If bodyForRetry failed, req's body can't be read twice, so we
+		// can't risk consuming it on a QUIC attempt that might fail and
+		// need a fallback retry: fall through to the fallback with req's
+		// original, untouched body.
+	}
+
+	rsp, err := rt.fallback().RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	rt.learnAltSvc(origin, rsp.Header.Get("Alt-Svc"))
+	return rsp, nil
+}
+
+// bodyForRetry returns a clone of req suitable for a one-shot attempt on a
+// second transport, leaving req's own body untouched so it can still be
+// used for a fallback retry. Requests with no body are always safe to
+// clone; a request with a body can only be cloned if req.GetBody is set
+// (as http.NewRequest arranges for common body types), since that's the
+// only way to obtain an independent copy of the body to read from.
+func bodyForRetry(req *http.Request) (*http.Request, error) {
+	clone := req.Clone(req.Context())
+	if req.Body == nil || req.Body == http.NoBody {
+		return clone, nil
+	}
+	if req.GetBody == nil {
+		return nil, errBodyNotReplayable
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	clone.Body = body
+	return clone, nil
+}
+
+func (rt *AltSvcRoundTripper) roundTripH3(req *http.Request, port string) (*http.Response, error) {
+	req.URL.Host = req.URL.Hostname() + ":" + port
+	return rt.h3.RoundTrip(req)
+}
+
+// learnAltSvc parses the Alt-Svc header of a response and, if it advertises
+// an "h3" endpoint, caches it for origin until its max-age expires.
+func (rt *AltSvcRoundTripper) learnAltSvc(origin, altSvc string) {
+	if altSvc == "" || altSvc == "clear" {
+		rt.mu.Lock()
+		delete(rt.cache, origin)
+		rt.mu.Unlock()
+		return
+	}
+	m := altSvcH3Entry.FindStringSubmatch(altSvc)
+	if m == nil {
+		return
+	}
+	maxAge := defaultAltSvcMaxAge
+	if m[2] != "" {
+		if s, err := strconv.Atoi(m[2]); err == nil {
+			maxAge = time.Duration(s) * time.Second
+		}
+	}
+	rt.mu.Lock()
+	rt.cache[origin] = altSvcEntry{port: m[1], expiry: time.Now().Add(maxAge)}
+	rt.mu.Unlock()
+}
+
+// Close closes the cached HTTP/3 round tripper and any connections it
+// currently holds open.
+func (rt *AltSvcRoundTripper) Close() error {
+	if rt.h3 == nil {
+		return nil
+	}
+	return rt.h3.Close()
+}
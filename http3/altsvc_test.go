@@ -0,0 +1,112 @@
+package http3
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLearnAltSvc(t *testing.T) {
+	rt := &AltSvcRoundTripper{}
+	rt.initOnce.Do(rt.init)
+
+	rt.learnAltSvc("https://example.com", `h3=":443"; ma=60`)
+	entry, ok := rt.cache["https://example.com"]
+	if !ok {
+		t.Fatal("expected an Alt-Svc entry to be cached")
+	}
+	if entry.port != "443" {
+		t.Fatalf("expected port 443, got %q", entry.port)
+	}
+	if d := time.Until(entry.expiry); d <= 0 || d > 60*time.Second {
+		t.Fatalf("expected expiry ~60s out, got %s", d)
+	}
+}
+
+func TestLearnAltSvcDefaultMaxAge(t *testing.T) {
+	rt := &AltSvcRoundTripper{}
+	rt.initOnce.Do(rt.init)
+
+	rt.learnAltSvc("https://example.com", `h3=":443"`)
+	entry := rt.cache["https://example.com"]
+	if d := time.Until(entry.expiry); d <= 23*time.Hour {
+		t.Fatalf("expected the default 24h max-age, got %s", d)
+	}
+}
+
+func TestLearnAltSvcNoH3(t *testing.T) {
+	rt := &AltSvcRoundTripper{}
+	rt.initOnce.Do(rt.init)
+	rt.cache["https://example.com"] = altSvcEntry{port: "443", expiry: time.Now().Add(time.Hour)}
+
+	rt.learnAltSvc("https://example.com", `h2=":443"; ma=60`)
+	if _, ok := rt.cache["https://example.com"]; !ok {
+		t.Fatal("an advertisement without h3 shouldn't evict an existing entry")
+	}
+}
+
+func TestLearnAltSvcClear(t *testing.T) {
+	rt := &AltSvcRoundTripper{}
+	rt.initOnce.Do(rt.init)
+	rt.cache["https://example.com"] = altSvcEntry{port: "443", expiry: time.Now().Add(time.Hour)}
+
+	rt.learnAltSvc("https://example.com", "clear")
+	if _, ok := rt.cache["https://example.com"]; ok {
+		t.Fatal("expected \"clear\" to evict the cached entry")
+	}
+}
+
+func TestBodyForRetryNoBody(t *testing.T) {
+	req, err := http.NewRequest(http.MethodGet, "https://example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bodyForRetry(req); err != nil {
+		t.Fatalf("unexpected error for a bodyless request: %s", err)
+	}
+}
+
+func TestBodyForRetryReplayableBody(t *testing.T) {
+	req, err := http.NewRequest(http.MethodPost, "https://example.com", strings.NewReader("payload"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.GetBody == nil {
+		t.Fatal("expected http.NewRequest to set GetBody for a strings.Reader body")
+	}
+
+	clone, err := bodyForRetry(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	b, err := io.ReadAll(clone.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "payload" {
+		t.Fatalf("expected the clone's body to read \"payload\", got %q", b)
+	}
+
+	// The original request's body must still be untouched.
+	orig, err := io.ReadAll(req.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(orig) != "payload" {
+		t.Fatalf("original request body was consumed, got %q", orig)
+	}
+}
+
+func TestBodyForRetryNonReplayableBody(t *testing.T) {
+	req, err := http.NewRequest(http.MethodPost, "https://example.com", io.NopCloser(strings.NewReader("payload")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.GetBody = nil
+
+	if _, err := bodyForRetry(req); err != errBodyNotReplayable {
+		t.Fatalf("expected errBodyNotReplayable, got %v", err)
+	}
+}
@@ -0,0 +1,197 @@
+package http3
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Authenticator produces credentials for an AuthenticatingRoundTripper in
+// response to a "401 WWW-Authenticate" challenge, and gets a chance to react
+// when credentials it previously produced are rejected.
+type Authenticator interface {
+	// Negotiate returns the value of the Authorization header to attach to
+	// req, given the WWW-Authenticate challenge that triggered it.
+	Negotiate(req *http.Request, challenge string) (header string, err error)
+	// OnChallenge is called when a request already carrying credentials
+	// from a previous Negotiate call is challenged again (e.g. an expired
+	// ticket), so the Authenticator can drop any cached state before the
+	// next Negotiate call is made.
+	OnChallenge(resp *http.Response) error
+}
+
+// AuthenticatingRoundTripper wraps a http.RoundTripper (typically a
+// *RoundTripper or *AltSvcRoundTripper) and answers 401 challenges using
+// Authenticator. Once a request to an origin retried with negotiated
+// credentials gets any response other than 401, the credentials are pinned
+// to that origin, so later requests to the same origin reuse them instead
+// of going through the challenge round trip again. A pin is dropped when
+// the origin answers a request carrying it with another 401.
+type AuthenticatingRoundTripper struct {
+	Transport     http.RoundTripper
+	Authenticator Authenticator
+
+	mu     sync.Mutex
+	pinned map[string]string // origin -> Authorization header
+}
+
+// RoundTrip implements http.RoundTripper.
+func (rt *AuthenticatingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	origin := req.URL.Scheme + "://" + req.URL.Host
+
+	rt.mu.Lock()
+	header, havePinned := rt.pinned[origin]
+	rt.mu.Unlock()
+	if havePinned {
+		req = req.Clone(req.Context())
+		req.Header.Set("Authorization", header)
+	}
+
+	resp, err := rt.Transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusUnauthorized || rt.Authenticator == nil {
+		return resp, nil
+	}
+
+	if havePinned {
+		// The pinned credentials we just attached were rejected; let the
+		// Authenticator drop any cached state tied to them.
+		if err := rt.Authenticator.OnChallenge(resp); err != nil {
+			resp.Body.Close()
+			return nil, err
+		}
+		rt.mu.Lock()
+		delete(rt.pinned, origin)
+		rt.mu.Unlock()
+	}
+
+	challenge := resp.Header.Get("WWW-Authenticate")
+	if challenge == "" {
+		return resp, nil
+	}
+	resp.Body.Close()
+
+	authHeader, err := rt.Authenticator.Negotiate(req, challenge)
+	if err != nil {
+		return nil, fmt.Errorf("http3: authentication failed: %w", err)
+	}
+	retry := req.Clone(req.Context())
+	retry.Header.Set("Authorization", authHeader)
+	retryResp, err := rt.Transport.RoundTrip(retry)
+	if err != nil {
+		return nil, err
+	}
+	if retryResp.StatusCode != http.StatusUnauthorized {
+		rt.mu.Lock()
+		if rt.pinned == nil {
+			rt.pinned = make(map[string]string)
+		}
+		rt.pinned[origin] = authHeader
+		rt.mu.Unlock()
+	}
+	return retryResp, nil
+}
+
+// BearerAuthenticator attaches a static bearer token.
+type BearerAuthenticator struct {
+	Token string
+}
+
+func (a *BearerAuthenticator) Negotiate(req *http.Request, challenge string) (string, error) {
+	return "Bearer " + a.Token, nil
+}
+
+func (a *BearerAuthenticator) OnChallenge(resp *http.Response) error {
+	return fmt.Errorf("http3: bearer token rejected by %s", resp.Request.URL.Host)
+}
+
+// NetrcAuthenticator authenticates using Basic auth credentials looked up
+// from a netrc file, mirroring how curl and git resolve per-host
+// credentials for HTTP(S).
+type NetrcAuthenticator struct {
+	// Path to the netrc file. Defaults to "$HOME/.netrc".
+	Path string
+}
+
+func (a *NetrcAuthenticator) Negotiate(req *http.Request, challenge string) (string, error) {
+	login, password, err := lookupNetrc(a.path(), req.URL.Hostname())
+	if err != nil {
+		return "", err
+	}
+	creds := base64.StdEncoding.EncodeToString([]byte(login + ":" + password))
+	return "Basic " + creds, nil
+}
+
+func (a *NetrcAuthenticator) OnChallenge(resp *http.Response) error {
+	return fmt.Errorf("http3: netrc credentials rejected by %s", resp.Request.URL.Host)
+}
+
+func (a *NetrcAuthenticator) path() string {
+	if a.Path != "" {
+		return a.Path
+	}
+	return os.Getenv("HOME") + "/.netrc"
+}
+
+// lookupNetrc does a minimal parse of a netrc file, returning the login and
+// password of the first "machine host" entry found.
+func lookupNetrc(path, host string) (login, password string, err error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", "", err
+	}
+	fields := strings.Fields(string(b))
+	for i := 0; i < len(fields); i++ {
+		if fields[i] != "machine" || i+1 >= len(fields) || fields[i+1] != host {
+			continue
+		}
+		for j := i + 2; j+1 < len(fields) && fields[j] != "machine"; j += 2 {
+			switch fields[j] {
+			case "login":
+				login = fields[j+1]
+			case "password":
+				password = fields[j+1]
+			}
+		}
+		return login, password, nil
+	}
+	return "", "", fmt.Errorf("http3: no netrc entry for host %q", host)
+}
+
+// SPNEGOAuthenticator implements SPNEGO/Kerberos negotiation: on a
+// challenge it generates a GSSAPI token for the request's service
+// principal name and replays the request with it in a "Negotiate"
+// Authorization header, the same flow git-lfs uses over HTTP/1.1 and
+// HTTP/2. Generating the token itself requires a platform GSSAPI library,
+// which doesn't belong in quic-go, so callers supply that piece via
+// GenerateToken.
+type SPNEGOAuthenticator struct {
+	// GenerateToken returns a base64-encoded GSSAPI token for the given
+	// service principal name (typically "HTTP/<host>").
+	GenerateToken func(spn string) (string, error)
+}
+
+func (a *SPNEGOAuthenticator) Negotiate(req *http.Request, challenge string) (string, error) {
+	if !strings.Contains(challenge, "Negotiate") {
+		return "", fmt.Errorf("http3: server did not offer Negotiate, got %q", challenge)
+	}
+	spn := "HTTP/" + req.URL.Hostname()
+	token, err := a.GenerateToken(spn)
+	if err != nil {
+		return "", fmt.Errorf("http3: generating GSSAPI token for %s: %w", spn, err)
+	}
+	return "Negotiate " + token, nil
+}
+
+// OnChallenge allows a fresh Negotiate attempt rather than failing outright:
+// a pinned GSSAPI token being rejected is an expected, routine event (e.g. a
+// Kerberos ticket expiring mid-session on a long-lived connection), and
+// GenerateToken is expected to acquire a new token on each call.
+func (a *SPNEGOAuthenticator) OnChallenge(resp *http.Response) error {
+	return nil
+}
@@ -0,0 +1,111 @@
+package http3
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+func TestAuthenticatingRoundTripperNegotiatesOnChallenge(t *testing.T) {
+	var calls int
+	inner := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		calls++
+		if req.Header.Get("Authorization") == "Bearer good" {
+			return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: req}, nil
+		}
+		return &http.Response{
+			StatusCode: http.StatusUnauthorized,
+			Header:     http.Header{"Www-Authenticate": []string{"Bearer"}},
+			Body:       http.NoBody,
+			Request:    req,
+		}, nil
+	})
+
+	rt := &AuthenticatingRoundTripper{Transport: inner, Authenticator: &BearerAuthenticator{Token: "good"}}
+	req := httptest.NewRequest(http.MethodGet, "https://example.com/file", nil)
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	if calls != 2 {
+		t.Fatalf("expected one challenge and one authenticated retry, got %d calls", calls)
+	}
+
+	// A second request to the same origin should reuse the pinned
+	// credentials without a challenge round trip.
+	calls = 0
+	req2 := httptest.NewRequest(http.MethodGet, "https://example.com/other", nil)
+	if _, err := rt.RoundTrip(req2); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 1 {
+		t.Fatalf("expected pinned credentials to skip the challenge, got %d calls", calls)
+	}
+}
+
+func TestAuthenticatingRoundTripperDropsPinOnChallenge(t *testing.T) {
+	inner := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusUnauthorized,
+			Header:     http.Header{"Www-Authenticate": []string{"Negotiate"}},
+			Body:       http.NoBody,
+			Request:    req,
+		}, nil
+	})
+
+	rt := &AuthenticatingRoundTripper{
+		Transport: inner,
+		Authenticator: &SPNEGOAuthenticator{
+			GenerateToken: func(spn string) (string, error) { return "token", nil },
+		},
+		pinned: map[string]string{"https://example.com": "Negotiate stale"},
+	}
+	req := httptest.NewRequest(http.MethodGet, "https://example.com/file", nil)
+	if _, err := rt.RoundTrip(req); err != nil {
+		t.Fatal(err)
+	}
+	if _, stillPinned := rt.pinned["https://example.com"]; stillPinned && rt.pinned["https://example.com"] == "Negotiate stale" {
+		t.Fatal("expected the stale pinned credentials to be dropped after OnChallenge")
+	}
+}
+
+func TestBearerAuthenticatorOnChallengeFails(t *testing.T) {
+	a := &BearerAuthenticator{Token: "t"}
+	req := httptest.NewRequest(http.MethodGet, "https://example.com", nil)
+	resp := &http.Response{Request: req}
+	if err := a.OnChallenge(resp); err == nil {
+		t.Fatal("expected a rejected bearer token to be a fatal error")
+	}
+}
+
+func TestSPNEGOAuthenticatorOnChallengeAllowsRenegotiate(t *testing.T) {
+	a := &SPNEGOAuthenticator{GenerateToken: func(spn string) (string, error) { return "tok", nil }}
+	req := httptest.NewRequest(http.MethodGet, "https://example.com", nil)
+	resp := &http.Response{Request: req}
+	if err := a.OnChallenge(resp); err != nil {
+		t.Fatalf("expected a rejected SPNEGO token to allow re-negotiation, got error: %s", err)
+	}
+}
+
+func TestSPNEGOAuthenticatorNegotiate(t *testing.T) {
+	a := &SPNEGOAuthenticator{GenerateToken: func(spn string) (string, error) { return "tok", nil }}
+	req := httptest.NewRequest(http.MethodGet, "https://example.com", nil)
+	header, err := a.Negotiate(req, "Negotiate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header != "Negotiate tok" {
+		t.Fatalf("expected %q, got %q", "Negotiate tok", header)
+	}
+
+	if _, err := a.Negotiate(req, "Basic"); err == nil {
+		t.Fatal("expected Negotiate to fail when the server doesn't offer it")
+	}
+}
@@ -0,0 +1,110 @@
+package handshake
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+)
+
+// ErrSessionVersionMismatch is recorded by VersionedClientSessionCache.Get,
+// and returned by its TakeError, when a lookup finds a session ticket that
+// was issued for a different QUIC version (or ALPN) than the one currently
+// set. 0-RTT transport parameters are version-specific, so resuming across
+// versions is unsafe. The crypto setup does not return this error itself:
+// it is only surfaced by a caller that checks TakeError.
+var ErrSessionVersionMismatch = errors.New("handshake: session ticket was issued for a different QUIC version")
+
+// VersionedClientSessionCache wraps a tls.ClientSessionCache and tags every
+// stored ticket with the QUIC version and ALPN that was active at the time
+// it was stored. The active version/ALPN is the one given to
+// NewVersionedClientSessionCache until SetNegotiationContext changes it;
+// the crypto setup does not call SetNegotiationContext, so the active
+// version/ALPN only changes when the owner of the cache sets it. Lookups
+// for a ticket stored under a version or ALPN
+// different from the current one miss the underlying cache (so the
+// handshake falls back safely) and record the mismatch so the caller can
+// surface ErrSessionVersionMismatch instead of treating it as an ordinary
+// "no ticket cached" miss.
+type VersionedClientSessionCache struct {
+	tls.ClientSessionCache
+
+	mutex     sync.Mutex
+	version   protocol.VersionNumber
+	alpn      string
+	lastError error
+	// issuedFor records, per plain (untagged) session key, the version and
+	// ALPN a ticket was last stored under, so Get can tell "never cached"
+	// apart from "cached, but for a different version".
+	issuedFor map[string]ticketTag
+}
+
+type ticketTag struct {
+	version protocol.VersionNumber
+	alpn    string
+}
+
+var _ tls.ClientSessionCache = &VersionedClientSessionCache{}
+
+// NewVersionedClientSessionCache wraps cache so that tickets are only ever
+// resumed if they were issued under the version and alpn currently set via
+// SetNegotiationContext (initially version and alpn, as given here).
+func NewVersionedClientSessionCache(cache tls.ClientSessionCache, version protocol.VersionNumber, alpn string) *VersionedClientSessionCache {
+	return &VersionedClientSessionCache{
+		ClientSessionCache: cache,
+		version:            version,
+		alpn:               alpn,
+		issuedFor:          make(map[string]ticketTag),
+	}
+}
+
+// SetNegotiationContext updates the QUIC version and ALPN that subsequent
+// Get and Put calls are evaluated against. Call this before starting each
+// new connection attempt on a client that reuses the same cache across
+// reconnects, so a ticket cached under a version that's no longer being
+// attempted is correctly treated as stale rather than silently resumed.
+func (c *VersionedClientSessionCache) SetNegotiationContext(version protocol.VersionNumber, alpn string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.version = version
+	c.alpn = alpn
+}
+
+// Get implements tls.ClientSessionCache.
+func (c *VersionedClientSessionCache) Get(sessionKey string) (*tls.ClientSessionState, bool) {
+	c.mutex.Lock()
+	version, alpn := c.version, c.alpn
+	tag, cached := c.issuedFor[sessionKey]
+	if cached && (tag.version != version || tag.alpn != alpn) {
+		c.lastError = ErrSessionVersionMismatch
+		c.mutex.Unlock()
+		return nil, false
+	}
+	c.mutex.Unlock()
+	return c.ClientSessionCache.Get(taggedSessionKey(sessionKey, version, alpn))
+}
+
+// Put implements tls.ClientSessionCache.
+func (c *VersionedClientSessionCache) Put(sessionKey string, cs *tls.ClientSessionState) {
+	c.mutex.Lock()
+	version, alpn := c.version, c.alpn
+	c.issuedFor[sessionKey] = ticketTag{version: version, alpn: alpn}
+	c.mutex.Unlock()
+	c.ClientSessionCache.Put(taggedSessionKey(sessionKey, version, alpn), cs)
+}
+
+// TakeError returns and clears the last version-mismatch error observed by
+// Get, or nil if none occurred since the last call.
+func (c *VersionedClientSessionCache) TakeError() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	err := c.lastError
+	c.lastError = nil
+	return err
+}
+
+func taggedSessionKey(key string, version protocol.VersionNumber, alpn string) string {
+	return fmt.Sprintf("%s|quic=%s|alpn=%s", key, version, alpn)
+}
@@ -0,0 +1,71 @@
+package handshake
+
+import (
+	"crypto/tls"
+	"testing"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+)
+
+func TestVersionedClientSessionCacheResumesSameVersion(t *testing.T) {
+	c := NewVersionedClientSessionCache(tls.NewLRUClientSessionCache(1), protocol.VersionNumber(1), "h3")
+	cs := &tls.ClientSessionState{}
+	c.Put("key", cs)
+
+	got, ok := c.Get("key")
+	if !ok || got != cs {
+		t.Fatal("expected a ticket stored and looked up under the same version and ALPN to hit")
+	}
+	if err := c.TakeError(); err != nil {
+		t.Fatalf("expected no mismatch error, got %s", err)
+	}
+}
+
+func TestVersionedClientSessionCacheDetectsVersionChange(t *testing.T) {
+	c := NewVersionedClientSessionCache(tls.NewLRUClientSessionCache(1), protocol.VersionNumber(1), "h3")
+	c.Put("key", &tls.ClientSessionState{})
+
+	// A version-negotiation round trip moved the client to a different
+	// QUIC version before the next connection attempt.
+	c.SetNegotiationContext(protocol.VersionNumber(2), "h3")
+
+	if _, ok := c.Get("key"); ok {
+		t.Fatal("expected a ticket issued for a different version to miss")
+	}
+	if err := c.TakeError(); err != ErrSessionVersionMismatch {
+		t.Fatalf("expected ErrSessionVersionMismatch, got %v", err)
+	}
+	// TakeError clears the recorded error.
+	if err := c.TakeError(); err != nil {
+		t.Fatalf("expected TakeError to clear the error after reading it once, got %s", err)
+	}
+}
+
+func TestVersionedClientSessionCacheDetectsALPNChange(t *testing.T) {
+	c := NewVersionedClientSessionCache(tls.NewLRUClientSessionCache(1), protocol.VersionNumber(1), "h3")
+	c.Put("key", &tls.ClientSessionState{})
+
+	c.SetNegotiationContext(protocol.VersionNumber(1), "h3-29")
+
+	if _, ok := c.Get("key"); ok {
+		t.Fatal("expected a ticket issued for a different ALPN to miss")
+	}
+	if err := c.TakeError(); err != ErrSessionVersionMismatch {
+		t.Fatalf("expected ErrSessionVersionMismatch, got %v", err)
+	}
+}
+
+func TestVersionedClientSessionCacheResumesAfterContextRestored(t *testing.T) {
+	c := NewVersionedClientSessionCache(tls.NewLRUClientSessionCache(1), protocol.VersionNumber(1), "h3")
+	cs := &tls.ClientSessionState{}
+	c.Put("key", cs)
+
+	c.SetNegotiationContext(protocol.VersionNumber(2), "h3")
+
+	// Switching back to the original version/ALPN should resume again.
+	c.SetNegotiationContext(protocol.VersionNumber(1), "h3")
+	got, ok := c.Get("key")
+	if !ok || got != cs {
+		t.Fatal("expected the ticket to resume once the original negotiation context is restored")
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"crypto/ed25519"
 	"crypto/tls"
 	"errors"
 	"flag"
@@ -9,17 +10,25 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"os/exec"
 	"strings"
 
+	quic "github.com/lucas-clemente/quic-go"
 	"github.com/lucas-clemente/quic-go/http3"
+	"github.com/lucas-clemente/quic-go/internal/handshake"
 	"github.com/lucas-clemente/quic-go/internal/protocol"
 	"github.com/lucas-clemente/quic-go/interop/http09"
+	"github.com/lucas-clemente/quic-go/interop/verify"
+	"github.com/lucas-clemente/quic-go/qlog"
 	"golang.org/x/sync/errgroup"
 )
 
+const qlogDir = "/logs/qlog"
+
 var errUnsupported = errors.New("unsupported test case")
 
 var tlsConf *tls.Config
+var quicConf *quic.Config
 
 func main() {
 	logFile, err := os.Create("/logs/log.txt")
@@ -41,6 +50,9 @@ func main() {
 		InsecureSkipVerify: true,
 		KeyLogWriter:       keyLog,
 	}
+	quicConf = &quic.Config{
+		Tracer: qlog.NewDirTracer(qlogDir, qlog.LevelFromEnv()),
+	}
 	testcase := os.Getenv("TESTCASE")
 	if err := runTestcase(testcase); err != nil {
 		if err == errUnsupported {
@@ -58,9 +70,18 @@ func runTestcase(testcase string) error {
 
 	switch testcase {
 	case "http3":
-		r := &http3.RoundTripper{TLSClientConfig: tlsConf}
+		if os.Getenv("ALTSVC_UPGRADE") != "" {
+			r := &http3.AltSvcRoundTripper{
+				Fallback:        &http.Transport{TLSClientConfig: tlsConf},
+				TLSClientConfig: tlsConf,
+				QuicConfig:      quicConf,
+			}
+			defer r.Close()
+			return downloadFiles(withAuth(r), urls, false)
+		}
+		r := &http3.RoundTripper{TLSClientConfig: tlsConf, QuicConfig: quicConf}
 		defer r.Close()
-		return downloadFiles(r, urls, false)
+		return downloadFiles(withAuth(r), urls, false)
 	case "handshake", "transfer", "retry":
 	case "multiconnect":
 		return runMultiConnectTest(urls)
@@ -70,11 +91,13 @@ func runTestcase(testcase string) error {
 		return runResumptionTest(urls, false)
 	case "zerortt":
 		return runResumptionTest(urls, true)
+	case "signed-transfer":
+		return runSignedTransferTest(urls)
 	default:
 		return errUnsupported
 	}
 
-	r := &http09.RoundTripper{TLSClientConfig: tlsConf}
+	r := &http09.RoundTripper{TLSClientConfig: tlsConf, QuicConfig: quicConf}
 	defer r.Close()
 	return downloadFiles(r, urls, false)
 }
@@ -96,7 +119,7 @@ func runVersionNegotiationTest(urls []string) error {
 
 func runMultiConnectTest(urls []string) error {
 	for _, url := range urls {
-		r := &http09.RoundTripper{TLSClientConfig: tlsConf}
+		r := &http09.RoundTripper{TLSClientConfig: tlsConf, QuicConfig: quicConf}
 		if err := downloadFile(r, url, false); err != nil {
 			return err
 		}
@@ -112,19 +135,62 @@ func runResumptionTest(urls []string, use0RTT bool) error {
 		return errors.New("expected at least 2 URLs")
 	}
 
-	tlsConf.ClientSessionCache = tls.NewLRUClientSessionCache(1)
+	sessionCache := handshake.NewVersionedClientSessionCache(
+		tls.NewLRUClientSessionCache(1), protocol.SupportedVersions[0], http09.NextProtoH09,
+	)
+	tlsConf.ClientSessionCache = sessionCache
 
 	// do the first transfer
-	r := &http09.RoundTripper{TLSClientConfig: tlsConf}
+	r := &http09.RoundTripper{TLSClientConfig: tlsConf, QuicConfig: quicConf}
 	if err := downloadFiles(r, urls[:1], false); err != nil {
 		return err
 	}
 	r.Close()
 
 	// reestablish the connection, using the session ticket that the server (hopefully provided)
-	r = &http09.RoundTripper{TLSClientConfig: tlsConf}
+	r = &http09.RoundTripper{TLSClientConfig: tlsConf, QuicConfig: quicConf}
 	defer r.Close()
-	return downloadFiles(r, urls[1:], use0RTT)
+	if err := downloadFiles(r, urls[1:], use0RTT); err != nil {
+		return err
+	}
+	if err := sessionCache.TakeError(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// withAuth wraps cl in a http3.AuthenticatingRoundTripper if the test run
+// opted into authenticated-origin testing via AUTH_BEARER_TOKEN, AUTH_NETRC,
+// or AUTH_SPNEGO_HELPER, otherwise it returns cl unchanged.
+func withAuth(cl http.RoundTripper) http.RoundTripper {
+	switch {
+	case os.Getenv("AUTH_BEARER_TOKEN") != "":
+		return &http3.AuthenticatingRoundTripper{
+			Transport:     cl,
+			Authenticator: &http3.BearerAuthenticator{Token: os.Getenv("AUTH_BEARER_TOKEN")},
+		}
+	case os.Getenv("AUTH_NETRC") != "":
+		return &http3.AuthenticatingRoundTripper{
+			Transport:     cl,
+			Authenticator: &http3.NetrcAuthenticator{},
+		}
+	case os.Getenv("AUTH_SPNEGO_HELPER") != "":
+		helper := os.Getenv("AUTH_SPNEGO_HELPER")
+		return &http3.AuthenticatingRoundTripper{
+			Transport: cl,
+			Authenticator: &http3.SPNEGOAuthenticator{
+				GenerateToken: func(spn string) (string, error) {
+					out, err := exec.Command(helper, spn).Output()
+					if err != nil {
+						return "", err
+					}
+					return strings.TrimSpace(string(out)), nil
+				},
+			},
+		}
+	default:
+		return cl
+	}
 }
 
 func downloadFiles(cl http.RoundTripper, urls []string, use0RTT bool) error {
@@ -161,3 +227,82 @@ func downloadFile(cl http.RoundTripper, url string, use0RTT bool) error {
 	_, err = io.Copy(file, rsp.Body)
 	return err
 }
+
+func runSignedTransferTest(urls []string) error {
+	root, err := verify.LoadRootKey("/certs/roots.pub")
+	if err != nil {
+		return err
+	}
+
+	r := &http09.RoundTripper{TLSClientConfig: tlsConf, QuicConfig: quicConf}
+	defer r.Close()
+
+	var g errgroup.Group
+	for _, u := range urls {
+		url := u
+		g.Go(func() error {
+			return downloadSignedFile(r, url, root)
+		})
+	}
+	return g.Wait()
+}
+
+// downloadSignedFile downloads url, together with its detached signature
+// manifest at "<url>.sig", hashing the body as it streams to a temp file.
+// The file is only renamed into /downloads once the manifest's signature
+// over that hash has been verified against root.
+func downloadSignedFile(cl http.RoundTripper, url string, root ed25519.PublicKey) error {
+	manifest, err := fetchManifest(cl, url+".sig")
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	rsp, err := cl.RoundTrip(req)
+	if err != nil {
+		return err
+	}
+	defer rsp.Body.Close()
+
+	dest := "/downloads" + req.URL.Path
+	tmp, err := os.CreateTemp("/downloads", "signed-transfer-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	hw := verify.NewHashingWriter(tmp)
+	if _, err := io.Copy(hw, rsp.Body); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := manifest.Verify(root, hw.Sum()); err != nil {
+		return fmt.Errorf("signed-transfer: %s: %w", url, err)
+	}
+	return os.Rename(tmp.Name(), dest)
+}
+
+func fetchManifest(cl http.RoundTripper, sigURL string) (*verify.Manifest, error) {
+	req, err := http.NewRequest(http.MethodGet, sigURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	rsp, err := cl.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	defer rsp.Body.Close()
+
+	b, err := io.ReadAll(rsp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return verify.ParseManifest(b)
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+
+	quic "github.com/lucas-clemente/quic-go"
+	"github.com/lucas-clemente/quic-go/http3"
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/interop/http09"
+	"github.com/lucas-clemente/quic-go/qlog"
+)
+
+const qlogDir = "/logs/qlog"
+
+func main() {
+	logFile, err := os.Create("/logs/log.txt")
+	if err != nil {
+		fmt.Printf("Could not create log file: %s\n", err.Error())
+		os.Exit(1)
+	}
+	defer logFile.Close()
+	log.SetOutput(logFile)
+
+	testcase := os.Getenv("TESTCASE")
+
+	certFile, keyFile := "/certs/cert.pem", "/certs/priv.key"
+	tlsConf, err := tlsConfigForTestcase(testcase, certFile, keyFile)
+	if err != nil {
+		fmt.Printf("Could not load TLS config: %s\n", err.Error())
+		os.Exit(1)
+	}
+
+	quicConf := &quic.Config{
+		Tracer: qlog.NewDirTracer(qlogDir, qlog.LevelFromEnv()),
+	}
+	if testcase == "retry" {
+		// Refusing every token forces the handshake through a Retry
+		// round trip, regardless of what the client sent.
+		quicConf.AcceptToken = func(_ net.Addr, _ *quic.Token) bool {
+			return false
+		}
+	}
+	if testcase == "resumption" || testcase == "zerortt" {
+		quicConf.Allow0RTT = true
+	}
+	if testcase == "versionnegotiation" {
+		// Advertise protocol.SupportedVersions, the versions quic-go
+		// speaks, in this server's Version Negotiation packets. The
+		// interop client for this testcase deliberately dials with a
+		// version outside that set and expects a VN packet listing it back.
+		quicConf.Versions = protocol.SupportedVersions
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/", http.FileServer(http.Dir("/www")))
+
+	switch testcase {
+	case "versionnegotiation", "handshake", "transfer", "retry", "resumption",
+		"zerortt", "chacha20", "multiconnect", "signed-transfer":
+		if err := runHTTP3AndHTTP09(mux, tlsConf, quicConf); err != nil {
+			fmt.Printf("Server failed: %s\n", err.Error())
+			os.Exit(1)
+		}
+	default:
+		fmt.Printf("unsupported test case: %s\n", testcase)
+		os.Exit(127)
+	}
+}
+
+// tlsConfigForTestcase loads the server certificate and, for the chacha20
+// testcase, restricts the cipher suite so the handshake is forced to use
+// ChaCha20-Poly1305 rather than AES-GCM.
+func tlsConfigForTestcase(testcase, certFile, keyFile string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	conf := &tls.Config{Certificates: []tls.Certificate{cert}}
+	if testcase == "chacha20" {
+		conf.CipherSuites = []uint16{tls.TLS_CHACHA20_POLY1305_SHA256}
+	}
+	return conf, nil
+}
+
+// runHTTP3AndHTTP09 serves /www over both HTTP/3 and the interop h09 ALPN on
+// a single shared :443 listener, since the interop-runner client picks
+// whichever ALPN it negotiated and both need to be reachable on the same
+// port for a given connection attempt. A single accept loop dispatches each
+// accepted connection to the right handler by its negotiated ALPN, since
+// two accept loops racing on the same listener would hand connections to
+// whichever one happened to win Accept, regardless of ALPN.
+func runHTTP3AndHTTP09(handler http.Handler, tlsConf *tls.Config, quicConf *quic.Config) error {
+	tlsConf.NextProtos = append(tlsConf.NextProtos, http3.NextProtoH3, http09.NextProtoH09)
+
+	ln, err := quic.ListenAddrEarly(":443", tlsConf, quicConf)
+	if err != nil {
+		return err
+	}
+	defer ln.Close()
+
+	h3 := &http3.Server{Server: &http.Server{Handler: handler, TLSConfig: tlsConf}}
+	h09 := &http09.Server{Server: &http.Server{Handler: handler, TLSConfig: tlsConf}}
+
+	for {
+		conn, err := ln.Accept(context.Background())
+		if err != nil {
+			return err
+		}
+		go dispatchConn(conn, h3, h09)
+	}
+}
+
+// dispatchConn routes an accepted connection to the HTTP/3 or h09 server
+// based on the ALPN it actually negotiated during the handshake.
+func dispatchConn(conn quic.EarlyConnection, h3 *http3.Server, h09 *http09.Server) {
+	switch conn.ConnectionState().TLS.NegotiatedProtocol {
+	case http3.NextProtoH3:
+		if err := h3.ServeQUICConn(conn); err != nil {
+			log.Printf("http3: serving connection failed: %s", err)
+		}
+	case http09.NextProtoH09:
+		if err := h09.ServeQUICConn(conn); err != nil {
+			log.Printf("h09: serving connection failed: %s", err)
+		}
+	default:
+		conn.CloseWithError(0, "unsupported ALPN")
+	}
+}
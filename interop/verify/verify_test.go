@@ -0,0 +1,152 @@
+package verify
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func signDelegatedKey(t *testing.T, rootPriv ed25519.PrivateKey, pub ed25519.PublicKey, expiry time.Time) DelegatedKey {
+	t.Helper()
+	d := DelegatedKey{PublicKey: pub, Expiry: expiry}
+	d.Signature = ed25519.Sign(rootPriv, d.signedMessage())
+	return d
+}
+
+func TestDelegatedKeyVerify(t *testing.T) {
+	rootPub, rootPriv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	delegatedPub, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := signDelegatedKey(t, rootPriv, delegatedPub, time.Now().Add(time.Hour))
+	if err := d.Verify(rootPub); err != nil {
+		t.Fatalf("expected a correctly signed, unexpired key to verify, got %s", err)
+	}
+}
+
+func TestDelegatedKeyVerifyExpired(t *testing.T) {
+	rootPub, rootPriv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	delegatedPub, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := signDelegatedKey(t, rootPriv, delegatedPub, time.Now().Add(-time.Hour))
+	if err := d.Verify(rootPub); err == nil {
+		t.Fatal("expected an expired delegated key to fail verification")
+	}
+}
+
+func TestDelegatedKeyVerifyWrongRoot(t *testing.T) {
+	rootPub, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, otherRootPriv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	delegatedPub, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := signDelegatedKey(t, otherRootPriv, delegatedPub, time.Now().Add(time.Hour))
+	if err := d.Verify(rootPub); err == nil {
+		t.Fatal("expected a signature from a different root key to fail verification")
+	}
+}
+
+func TestDelegatedKeyVerifyWrongSize(t *testing.T) {
+	rootPub, rootPriv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := signDelegatedKey(t, rootPriv, []byte("too-short"), time.Now().Add(time.Hour))
+	if err := d.Verify(rootPub); err == nil {
+		t.Fatal("expected a malformed public key to fail verification, not panic")
+	}
+}
+
+func TestManifestVerify(t *testing.T) {
+	rootPub, rootPriv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	delegatedPub, delegatedPriv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := signDelegatedKey(t, rootPriv, delegatedPub, time.Now().Add(time.Hour))
+
+	digest := []byte("0123456789abcdef0123456789abcdef")
+	m := &Manifest{DelegatedKey: d, Signature: ed25519.Sign(delegatedPriv, digest)}
+
+	if err := m.Verify(rootPub, digest); err != nil {
+		t.Fatalf("expected a correctly signed manifest to verify, got %s", err)
+	}
+	if err := m.Verify(rootPub, []byte("different digest")); err == nil {
+		t.Fatal("expected verification to fail against a mismatched digest")
+	}
+}
+
+func TestParseManifest(t *testing.T) {
+	rootPub, rootPriv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	delegatedPub, delegatedPriv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := signDelegatedKey(t, rootPriv, delegatedPub, time.Now().Add(time.Hour))
+	digest := []byte("digest-bytes")
+	want := &Manifest{DelegatedKey: d, Signature: ed25519.Sign(delegatedPriv, digest)}
+
+	b, err := json.Marshal(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ParseManifest(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := got.Verify(rootPub, digest); err != nil {
+		t.Fatalf("round-tripped manifest failed to verify: %s", err)
+	}
+
+	if _, err := ParseManifest([]byte("not json")); err == nil {
+		t.Fatal("expected malformed JSON to fail to parse")
+	}
+}
+
+func TestHashingWriter(t *testing.T) {
+	var buf bytes.Buffer
+	hw := NewHashingWriter(&buf)
+	if _, err := hw.Write([]byte("hello, ")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := hw.Write([]byte("world")); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "hello, world" {
+		t.Fatalf("expected the writes to pass through, got %q", buf.String())
+	}
+
+	sum := sha256.Sum256([]byte("hello, world"))
+	want := sum[:]
+	if !bytes.Equal(hw.Sum(), want) {
+		t.Fatalf("expected digest %x, got %x", want, hw.Sum())
+	}
+}
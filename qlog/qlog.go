@@ -0,0 +1,313 @@
+// Package qlog implements a qlog writer for quic-go.
+//
+// It produces one JSON-SEQ encoded trace per connection, following the
+// event schema used by the quic-interop-runner, so traces captured during
+// interop testing can be fed directly into qvis and similar tooling.
+package qlog
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/lucas-clemente/quic-go/logging"
+)
+
+// recordSeparator is the ASCII RS byte that precedes every JSON-SEQ record,
+// as required by RFC 7464 and expected by the quic-interop-runner tooling.
+const recordSeparator = byte(0x1e)
+
+// Level controls how much detail is recorded in a trace. Each level is a
+// superset of the ones before it.
+type Level uint8
+
+const (
+	// LevelEndpoint records only connection lifecycle events (started,
+	// version negotiated, closed).
+	LevelEndpoint Level = iota
+	// LevelConnection additionally records packets sent and received.
+	LevelConnection
+	// LevelFrame additionally records the frames contained in each packet.
+	LevelFrame
+)
+
+// LevelFromEnv reads the QLOG_LEVEL environment variable ("endpoint",
+// "connection" or "frame") and returns the corresponding Level, defaulting
+// to LevelFrame if the variable is unset or unrecognized.
+func LevelFromEnv() Level {
+	switch os.Getenv("QLOG_LEVEL") {
+	case "endpoint":
+		return LevelEndpoint
+	case "connection":
+		return LevelConnection
+	default:
+		return LevelFrame
+	}
+}
+
+// flushInterval is how often buffered events are flushed to disk by the
+// background flusher, so a crashed process still leaves a usable partial
+// trace behind.
+const flushInterval = 100 * time.Millisecond
+
+// NewDirTracer returns a logging.Tracer that writes one qlog file per
+// connection into dir, named after the connection's original destination
+// connection ID (<odcid>.qlog). It's intended to be assigned to
+// quic.Config.Tracer so every connection accepted or dialed by that config
+// gets its own trace.
+func NewDirTracer(dir string, level Level) logging.Tracer {
+	return &dirTracer{dir: dir, level: level}
+}
+
+// dirTracer implements logging.Tracer. It doesn't itself record anything:
+// quic-go only calls its endpoint-level methods for packets that can't yet
+// be attributed to an established connection (e.g. packets for an unknown
+// connection ID), which aren't useful for interop post-mortem analysis, so
+// they're ignored. Its only real job is to hand out a fresh
+// connectionTracer, writing to its own file, for every connection.
+type dirTracer struct {
+	dir   string
+	level Level
+}
+
+var _ logging.Tracer = &dirTracer{}
+
+// TracerForConnection implements logging.Tracer.
+func (t *dirTracer) TracerForConnection(_ context.Context, p logging.Perspective, odcid logging.ConnectionID) logging.ConnectionTracer {
+	ct, err := newConnectionTracer(t.dir, odcid.Bytes(), p, t.level)
+	if err != nil {
+		// A qlog file we can't create shouldn't take down the connection;
+		// log to stderr and continue without tracing.
+		fmt.Fprintf(os.Stderr, "qlog: could not create trace file: %s\n", err)
+		return nil
+	}
+	return ct
+}
+
+func (t *dirTracer) SentPacket(net.Addr, *logging.Header, logging.ByteCount, []logging.Frame) {}
+func (t *dirTracer) SentVersionNegotiationPacket(net.Addr, logging.ArbitraryLenConnectionID, logging.ArbitraryLenConnectionID, []logging.VersionNumber) {
+}
+func (t *dirTracer) DroppedPacket(net.Addr, logging.PacketType, logging.ByteCount, logging.PacketDropReason) {
+}
+
+type connectionTracer struct {
+	mu    sync.Mutex
+	file  *os.File
+	w     *bufio.Writer
+	level Level
+	start time.Time
+
+	perspective logging.Perspective
+	odcid       string
+
+	closeFlusher chan struct{}
+}
+
+var _ logging.ConnectionTracer = &connectionTracer{}
+
+func newConnectionTracer(dir string, odcid []byte, p logging.Perspective, level Level) (*connectionTracer, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	name := hex.EncodeToString(odcid)
+	f, err := os.Create(filepath.Join(dir, name+".qlog"))
+	if err != nil {
+		return nil, err
+	}
+	t := &connectionTracer{
+		file:         f,
+		w:            bufio.NewWriter(f),
+		level:        level,
+		start:        time.Now(),
+		perspective:  p,
+		odcid:        name,
+		closeFlusher: make(chan struct{}),
+	}
+	t.logEvent(LevelEndpoint, "transport", "connection_started", map[string]interface{}{
+		"vantage_point": t.perspective.String(),
+		"odcid":         t.odcid,
+	})
+	go t.runFlusher()
+	return t, nil
+}
+
+func (t *connectionTracer) runFlusher() {
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			t.mu.Lock()
+			t.w.Flush()
+			t.mu.Unlock()
+		case <-t.closeFlusher:
+			return
+		}
+	}
+}
+
+// logEvent appends a single JSON-SEQ record if the tracer's configured
+// level is at least as detailed as minLevel. The recorded time is relative
+// to the connection's start, matching the "relative_time" reference point
+// used by the quic-interop-runner's qlog tooling.
+func (t *connectionTracer) logEvent(minLevel Level, category, name string, data interface{}) {
+	if t.level < minLevel {
+		return
+	}
+	entry := map[string]interface{}{
+		"time": time.Since(t.start).Seconds(),
+		"name": category + ":" + name,
+		"data": data,
+	}
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.w.WriteByte(recordSeparator)
+	t.w.Write(b)
+	t.w.WriteByte('\n')
+}
+
+// StartedConnection is called once the connection's local and remote
+// addresses are known.
+func (t *connectionTracer) StartedConnection(local, remote net.Addr, srcConnID, destConnID logging.ConnectionID) {
+	t.logEvent(LevelEndpoint, "transport", "connection_started", map[string]interface{}{
+		"local":  local.String(),
+		"remote": remote.String(),
+	})
+}
+
+// NegotiatedVersion records the QUIC version both endpoints settled on.
+func (t *connectionTracer) NegotiatedVersion(chosen logging.VersionNumber, clientVersions, serverVersions []logging.VersionNumber) {
+	t.logEvent(LevelEndpoint, "transport", "version_information", map[string]interface{}{"chosen_version": chosen})
+}
+
+// ClosedConnection records why a connection was closed.
+func (t *connectionTracer) ClosedConnection(err error) {
+	t.logEvent(LevelEndpoint, "transport", "connection_closed", map[string]interface{}{
+		"reason": err.Error(),
+	})
+}
+
+func (t *connectionTracer) SentTransportParameters(tp *logging.TransportParameters) {
+	t.logEvent(LevelConnection, "transport", "parameters_set", tp)
+}
+
+func (t *connectionTracer) ReceivedTransportParameters(tp *logging.TransportParameters) {
+	t.logEvent(LevelConnection, "transport", "parameters_set", tp)
+}
+
+func (t *connectionTracer) RestoredTransportParameters(tp *logging.TransportParameters) {
+	t.logEvent(LevelConnection, "transport", "parameters_restored", tp)
+}
+
+// SentPacket is called by quic-go whenever a packet is sent on this
+// connection. ack carries the ACK frame coalesced into the packet, if any,
+// separately from the rest of the frame list.
+func (t *connectionTracer) SentPacket(hdr *logging.ExtendedHeader, size logging.ByteCount, ack *logging.AckFrame, frames []logging.Frame) {
+	data := map[string]interface{}{"header": hdr, "size": size}
+	if t.level >= LevelFrame {
+		if ack != nil {
+			frames = append(frames, ack)
+		}
+		data["frames"] = frames
+	}
+	t.logEvent(LevelConnection, "transport", "packet_sent", data)
+}
+
+// ReceivedPacket is called by quic-go whenever a packet is received on this connection.
+func (t *connectionTracer) ReceivedPacket(hdr *logging.ExtendedHeader, size logging.ByteCount, frames []logging.Frame) {
+	data := map[string]interface{}{"header": hdr, "size": size}
+	if t.level >= LevelFrame {
+		data["frames"] = frames
+	}
+	t.logEvent(LevelConnection, "transport", "packet_received", data)
+}
+
+func (t *connectionTracer) ReceivedVersionNegotiationPacket(dest, src logging.ArbitraryLenConnectionID, versions []logging.VersionNumber) {
+	t.logEvent(LevelConnection, "transport", "packet_received", map[string]interface{}{"versions": versions})
+}
+
+func (t *connectionTracer) ReceivedRetry(hdr *logging.Header) {
+	t.logEvent(LevelConnection, "transport", "packet_received", map[string]interface{}{"header": hdr})
+}
+
+func (t *connectionTracer) BufferedPacket(pt logging.PacketType) {
+	t.logEvent(LevelConnection, "transport", "packet_buffered", map[string]interface{}{"packet_type": pt})
+}
+
+func (t *connectionTracer) DroppedPacket(pt logging.PacketType, size logging.ByteCount, reason logging.PacketDropReason) {
+	t.logEvent(LevelConnection, "transport", "packet_dropped", map[string]interface{}{"packet_type": pt, "size": size, "trigger": reason})
+}
+
+func (t *connectionTracer) UpdatedMetrics(rttStats *logging.RTTStats, cwnd, bytesInFlight logging.ByteCount, packetsInFlight int) {
+	t.logEvent(LevelConnection, "recovery", "metrics_updated", map[string]interface{}{
+		"cwnd": cwnd, "bytes_in_flight": bytesInFlight, "packets_in_flight": packetsInFlight,
+	})
+}
+
+func (t *connectionTracer) LostPacket(level logging.EncryptionLevel, number logging.PacketNumber, reason logging.PacketLossReason) {
+	t.logEvent(LevelConnection, "recovery", "packet_lost", map[string]interface{}{"packet_number": number, "trigger": reason})
+}
+
+func (t *connectionTracer) UpdatedCongestionState(state logging.CongestionState) {
+	t.logEvent(LevelConnection, "recovery", "congestion_state_updated", map[string]interface{}{"new": state})
+}
+
+func (t *connectionTracer) UpdatedPTOCount(value uint32) {
+	t.logEvent(LevelConnection, "recovery", "metrics_updated", map[string]interface{}{"pto_count": value})
+}
+
+func (t *connectionTracer) UpdatedKeyFromTLS(level logging.EncryptionLevel, perspective logging.Perspective) {
+	t.logEvent(LevelConnection, "security", "key_updated", map[string]interface{}{"encryption_level": level})
+}
+
+func (t *connectionTracer) UpdatedKey(generation logging.KeyPhase, remote bool) {
+	t.logEvent(LevelConnection, "security", "key_updated", map[string]interface{}{"key_phase": generation, "remote": remote})
+}
+
+func (t *connectionTracer) DroppedEncryptionLevel(level logging.EncryptionLevel) {
+	t.logEvent(LevelConnection, "security", "key_discarded", map[string]interface{}{"encryption_level": level})
+}
+
+func (t *connectionTracer) DroppedKey(generation logging.KeyPhase) {
+	t.logEvent(LevelConnection, "security", "key_discarded", map[string]interface{}{"key_phase": generation})
+}
+
+func (t *connectionTracer) SetLossTimer(kind logging.TimerType, level logging.EncryptionLevel, at time.Time) {
+	t.logEvent(LevelFrame, "recovery", "loss_timer_updated", map[string]interface{}{"event_type": "set", "timer_type": kind})
+}
+
+func (t *connectionTracer) LossTimerExpired(kind logging.TimerType, level logging.EncryptionLevel) {
+	t.logEvent(LevelFrame, "recovery", "loss_timer_updated", map[string]interface{}{"event_type": "expired", "timer_type": kind})
+}
+
+func (t *connectionTracer) LossTimerCanceled() {
+	t.logEvent(LevelFrame, "recovery", "loss_timer_updated", map[string]interface{}{"event_type": "cancelled"})
+}
+
+// Debug records a free-form debug message under the named tag.
+func (t *connectionTracer) Debug(name, msg string) {
+	t.logEvent(LevelFrame, "debug", name, msg)
+}
+
+// Close flushes and closes the underlying qlog file. It's safe to call once
+// the connection this tracer was created for has been closed.
+func (t *connectionTracer) Close() error {
+	close(t.closeFlusher)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err := t.w.Flush(); err != nil {
+		return err
+	}
+	return t.file.Close()
+}